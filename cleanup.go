@@ -0,0 +1,73 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// cleanupStacks maps a *TestInfo to the cleanup functions registered
+// against it via TestInfo.Cleanup, in registration order. It's a
+// side-table rather than a field on TestInfo so that Cleanup and TempDir
+// can live alongside the rest of TestInfo's API without touching its
+// definition.
+var cleanupStacks sync.Map // *TestInfo -> *[]func()
+
+// Cleanup registers fn to be called after the test (and its TearDown
+// method, if any) has finished, in LIFO order relative to other calls to
+// Cleanup. A panicking cleanup function doesn't prevent the others from
+// running. This is the ogletest analogue of testing.T.Cleanup, and removes
+// the need for suites to hand-write teardown logic that mirrors the order
+// resources were acquired in.
+func (i *TestInfo) Cleanup(fn func()) {
+	v, _ := cleanupStacks.LoadOrStore(i, &[]func(){})
+	stack := v.(*[]func())
+	*stack = append(*stack, fn)
+}
+
+// TempDir returns a new, empty directory for use by the currently-running
+// test. The directory and everything in it are removed automatically via
+// Cleanup once the test finishes.
+func (i *TestInfo) TempDir() string {
+	dir, err := ioutil.TempDir("", "ogletest")
+	if err != nil {
+		panic(fmt.Sprintf("TempDir: %v", err))
+	}
+
+	i.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+// runCleanups invokes every cleanup function registered against i via
+// Cleanup, most-recently-registered first, then forgets about i entirely.
+// It must be called with i set as the currently-running test, since
+// runWithProtection reports panics against whatever that is.
+func runCleanups(i *TestInfo) {
+	v, ok := cleanupStacks.Load(i)
+	if !ok {
+		return
+	}
+	cleanupStacks.Delete(i)
+
+	stack := *(v.(*[]func()))
+	for idx := len(stack) - 1; idx >= 0; idx-- {
+		fn := stack[idx]
+		runWithProtection(fn)
+	}
+}
@@ -0,0 +1,83 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"os"
+	"testing"
+)
+
+func TestCleanupRunsInLIFOOrder(t *testing.T) {
+	info := newTestInfo()
+
+	var order []int
+	info.Cleanup(func() { order = append(order, 1) })
+	info.Cleanup(func() { order = append(order, 2) })
+	info.Cleanup(func() { order = append(order, 3) })
+
+	runCleanups(info)
+
+	want := []int{3, 2, 1}
+	if len(order) != len(want) {
+		t.Fatalf("got %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("got %v, want %v", order, want)
+		}
+	}
+}
+
+func TestRunCleanupsIsANoOpWhenNothingIsRegistered(t *testing.T) {
+	info := newTestInfo()
+
+	// No cleanups registered; this should simply be a no-op.
+	runCleanups(info)
+}
+
+func TestCleanupForgetsTestInfoAfterRunning(t *testing.T) {
+	info := newTestInfo()
+
+	ran := false
+	info.Cleanup(func() { ran = true })
+	runCleanups(info)
+
+	if !ran {
+		t.Fatal("cleanup should have run")
+	}
+
+	// Running again should be a no-op, since runCleanups forgets info.
+	ran = false
+	runCleanups(info)
+	if ran {
+		t.Error("cleanup should not run a second time")
+	}
+}
+
+func TestTempDirReturnsADirectoryRemovedByCleanup(t *testing.T) {
+	info := newTestInfo()
+
+	dir := info.TempDir()
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		t.Fatalf("TempDir() = %q, which is not a directory: %v", dir, err)
+	}
+
+	runCleanups(info)
+
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected %q to be removed after cleanup, got err=%v", dir, err)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time that ogletest-driven code should use instead
+// of calling the time package directly, so that tests can substitute a
+// FakeClock and drive time-dependent behavior deterministically. TestInfo
+// exposes one of these as the Clock field; it defaults to a real clock
+// backed by the time package, but a suite can swap in a FakeClock (e.g. in
+// SetUp) to take control of it.
+type Clock interface {
+	// Now returns the current time according to this clock.
+	Now() time.Time
+
+	// Since returns the amount of time that has elapsed since t, according
+	// to this clock.
+	Since(t time.Time) time.Duration
+
+	// Sleep blocks until d has elapsed according to this clock.
+	Sleep(d time.Duration)
+
+	// NewTimer returns a timer that will fire after d has elapsed according
+	// to this clock.
+	NewTimer(d time.Duration) Timer
+
+	// After is shorthand for NewTimer(d).C().
+	After(d time.Duration) <-chan time.Time
+}
+
+// Timer mirrors the subset of time.Timer that Clock implementations need to
+// support.
+type Timer interface {
+	// C returns the channel on which the time will be sent when the timer
+	// fires.
+	C() <-chan time.Time
+
+	// Stop prevents the timer from firing, as with time.Timer.Stop.
+	Stop() bool
+}
+
+// realClock is the default Clock, backed directly by the time package.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) Since(t time.Time) time.Duration {
+	return time.Since(t)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time { return r.t.C }
+func (r realTimer) Stop() bool          { return r.t.Stop() }
+
+// NewClock returns the default, real-time Clock implementation.
+func NewClock() Clock {
+	return realClock{}
+}
+
+// waiter is a goroutine parked in Sleep or After on a FakeClock, waiting for
+// the clock to advance past deadline.
+type waiter struct {
+	deadline time.Time
+	c        chan time.Time
+}
+
+// FakeClock is a Clock whose notion of the current time only changes when
+// Advance or Set is called, making time-dependent code deterministic to
+// test. It's modeled on the benbjohnson/clock pattern: suites that accept a
+// Clock instead of calling the time package directly can be driven entirely
+// by a FakeClock in tests.
+//
+// The zero value is not usable; use NewFakeClock.
+type FakeClock struct {
+	mutex   sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// NewFakeClock returns a FakeClock whose current time is initially t.
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	w := &waiter{
+		deadline: c.now.Add(d),
+		c:        make(chan time.Time, 1),
+	}
+	c.waiters = append(c.waiters, w)
+
+	return fakeTimer{clock: c, w: w}
+}
+
+// Set moves the clock's current time to t directly, firing any waiters
+// whose deadline has now passed. t must not be before the current time.
+func (c *FakeClock) Set(t time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advanceLocked(t)
+}
+
+// Advance moves the clock's current time forward by d, firing any waiters
+// whose deadline has now passed.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.advanceLocked(c.now.Add(d))
+}
+
+func (c *FakeClock) advanceLocked(t time.Time) {
+	c.now = t
+
+	var remaining []*waiter
+	for _, w := range c.waiters {
+		if w.deadline.After(c.now) {
+			remaining = append(remaining, w)
+			continue
+		}
+
+		w.c <- c.now
+	}
+
+	c.waiters = remaining
+}
+
+// BlockUntil blocks until at least n goroutines are parked waiting on this
+// clock (in Sleep, After, or a timer created with NewTimer), making it
+// possible to synchronize a test with a goroutine under test before calling
+// Advance.
+func (c *FakeClock) BlockUntil(n int) {
+	for {
+		c.mutex.Lock()
+		count := len(c.waiters)
+		c.mutex.Unlock()
+
+		if count >= n {
+			return
+		}
+
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// pendingDeadlines returns the deadlines of all current waiters, sorted,
+// primarily for use in tests of FakeClock itself.
+func (c *FakeClock) pendingDeadlines() []time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	deadlines := make([]time.Time, len(c.waiters))
+	for i, w := range c.waiters {
+		deadlines[i] = w.deadline
+	}
+
+	sort.Slice(deadlines, func(i, j int) bool { return deadlines[i].Before(deadlines[j]) })
+	return deadlines
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	w     *waiter
+}
+
+func (f fakeTimer) C() <-chan time.Time { return f.w.c }
+
+func (f fakeTimer) Stop() bool {
+	c := f.clock
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for i, w := range c.waiters {
+		if w == f.w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,111 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClockAdvanceFiresDueWaiters(t *testing.T) {
+	epoch := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(epoch)
+
+	early := c.After(time.Second)
+	late := c.After(time.Minute)
+
+	c.Advance(time.Second)
+
+	select {
+	case got := <-early:
+		if want := epoch.Add(time.Second); !got.Equal(want) {
+			t.Errorf("early fired with %v, want %v", got, want)
+		}
+	default:
+		t.Error("early should have fired after Advance(time.Second)")
+	}
+
+	select {
+	case got := <-late:
+		t.Errorf("late should not have fired yet, got %v", got)
+	default:
+	}
+
+	if got, want := len(c.pendingDeadlines()), 1; got != want {
+		t.Errorf("pendingDeadlines: got %d entries, want %d", got, want)
+	}
+}
+
+func TestFakeClockSetMovesTimeDirectly(t *testing.T) {
+	epoch := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(epoch)
+
+	later := epoch.Add(time.Hour)
+	c.Set(later)
+
+	if got := c.Now(); !got.Equal(later) {
+		t.Errorf("Now() = %v, want %v", got, later)
+	}
+}
+
+func TestFakeClockSinceUsesFakeTime(t *testing.T) {
+	epoch := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(epoch)
+
+	c.Advance(5 * time.Second)
+
+	if got, want := c.Since(epoch), 5*time.Second; got != want {
+		t.Errorf("Since(epoch) = %v, want %v", got, want)
+	}
+}
+
+func TestFakeClockBlockUntilUnblocksOnceParked(t *testing.T) {
+	epoch := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(epoch)
+
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Second)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Second)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine parked in Sleep never woke up after Advance")
+	}
+}
+
+func TestFakeTimerStopRemovesWaiter(t *testing.T) {
+	epoch := time.Date(2012, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewFakeClock(epoch)
+
+	timer := c.NewTimer(time.Second)
+	if stopped := timer.Stop(); !stopped {
+		t.Fatal("Stop() on an unfired timer should report true")
+	}
+
+	c.Advance(time.Minute)
+
+	select {
+	case <-timer.C():
+		t.Error("a stopped timer should never fire")
+	default:
+	}
+}
@@ -0,0 +1,572 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// oglemockgen generates ogletest/oglemock-compatible mocks for the
+// interfaces named by -interfaces, declared in -source. The generated mocks
+// can be driven with ogletest.ExpectCall exactly like the hand-written mocks
+// under test_cases/mock_image.
+//
+// Typical usage, via a go:generate directive next to the interfaces being
+// mocked:
+//
+//	//go:generate oglemockgen -source=$GOFILE -interfaces=Foo,Bar -destination=mock_foo.go -package=mock_foo
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"strings"
+)
+
+var fSource = flag.String(
+	"source",
+	"",
+	"Path to the Go source file declaring the interfaces to mock.")
+
+var fInterfaces = flag.String(
+	"interfaces",
+	"",
+	"Comma-separated list of interface names within -source to mock.")
+
+var fDestination = flag.String(
+	"destination",
+	"",
+	"Path to write the generated mock to. Defaults to stdout.")
+
+var fPackage = flag.String(
+	"package",
+	"",
+	"Package name for the generated file. Defaults to the source file's own package.")
+
+var fSourcePackage = flag.String(
+	"source-package",
+	"",
+	"Import path of the package declaring -source. Only required if "+
+		"-package names a different package than -source's own and a "+
+		"mocked method references a type declared in -source itself, so "+
+		"the generated mock can qualify and import it.")
+
+func main() {
+	flag.Parse()
+
+	if *fSource == "" || *fInterfaces == "" {
+		fmt.Fprintln(os.Stderr, "Usage: oglemockgen -source=file.go -interfaces=Foo,Bar [-destination=mock_foo.go] [-package=mock_foo]")
+		os.Exit(1)
+	}
+
+	if err := run(); err != nil {
+		log.Fatalf("oglemockgen: %v", err)
+	}
+}
+
+func run() error {
+	names := strings.Split(*fInterfaces, ",")
+	for i := range names {
+		names[i] = strings.TrimSpace(names[i])
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, *fSource, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("parsing %s: %v", *fSource, err)
+	}
+
+	interfaces, err := findInterfaces(file, names)
+	if err != nil {
+		return err
+	}
+
+	pkgName := *fPackage
+	if pkgName == "" {
+		pkgName = file.Name.Name
+	}
+
+	var buf bytes.Buffer
+	if err := renderMocks(&buf, fset, file, pkgName, interfaces); err != nil {
+		return err
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		// Emit the unformatted source too, so the syntax error is easy to
+		// track down against the generated (if broken) file.
+		return fmt.Errorf("formatting generated mock: %v\n\n%s", err, buf.String())
+	}
+
+	out := os.Stdout
+	if *fDestination != "" {
+		f, err := os.Create(*fDestination)
+		if err != nil {
+			return fmt.Errorf("creating %s: %v", *fDestination, err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	_, err = out.Write(formatted)
+	return err
+}
+
+// namedInterface is an interface type found in the source file, along with
+// the name it was declared under.
+type namedInterface struct {
+	name string
+	typ  *ast.InterfaceType
+}
+
+// findInterfaces locates each of names as a top-level interface type
+// declaration in file, returning an error naming whichever ones weren't
+// found.
+func findInterfaces(file *ast.File, names []string) ([]namedInterface, error) {
+	wanted := make(map[string]bool, len(names))
+	for _, n := range names {
+		wanted[n] = true
+	}
+
+	var found []namedInterface
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+
+			ifaceType, ok := typeSpec.Type.(*ast.InterfaceType)
+			if !ok || !wanted[typeSpec.Name.Name] {
+				continue
+			}
+
+			found = append(found, namedInterface{name: typeSpec.Name.Name, typ: ifaceType})
+			delete(wanted, typeSpec.Name.Name)
+		}
+	}
+
+	if len(wanted) > 0 {
+		var missing []string
+		for n := range wanted {
+			missing = append(missing, n)
+		}
+		return nil, fmt.Errorf("interface(s) not found in %s: %s", *fSource, strings.Join(missing, ", "))
+	}
+
+	return found, nil
+}
+
+// renderMocks writes a complete Go source file defining a mock for each
+// interface in interfaces.
+func renderMocks(buf *bytes.Buffer, fset *token.FileSet, src *ast.File, pkgName string, interfaces []namedInterface) error {
+	fmt.Fprintf(buf, "// Automatically generated by oglemockgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(buf, "package %s\n\n", pkgName)
+
+	// If we're generating into a different package than -source's own (the
+	// documented, common case), a mocked method that references a type
+	// declared in -source itself needs that reference qualified with
+	// -source-package and an import for it; within the same package, such a
+	// reference already resolves on its own and needs neither.
+	samePackage := pkgName == src.Name.Name
+	localTypes := localTypeNames(src)
+
+	sourceQualifier := ""
+	needsSourceImport := false
+	if !samePackage {
+		needsSourceImport = referencesLocalTypes(interfaces, localTypes)
+		if needsSourceImport && *fSourcePackage == "" {
+			return fmt.Errorf(
+				"a mocked method references a type declared in %s itself, but "+
+					"-package (%s) differs from its own package (%s); pass "+
+					"-source-package with %s's import path so the generated "+
+					"mock can qualify and import it",
+				*fSource, pkgName, src.Name.Name, *fSource)
+		}
+		sourceQualifier = packageLocalName(*fSourcePackage)
+	}
+
+	if err := renderImports(buf, src, interfaces, needsSourceImport); err != nil {
+		return err
+	}
+
+	tr := &typeRenderer{
+		fset:            fset,
+		localTypes:      localTypes,
+		samePackage:     samePackage,
+		sourceQualifier: sourceQualifier,
+	}
+
+	for _, iface := range interfaces {
+		if err := renderMock(buf, tr, iface); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// localTypeNames returns the names of every top-level type declared in src,
+// which renderMocks uses to recognize when a mocked method signature
+// references one of them rather than a type from an import.
+func localTypeNames(src *ast.File) map[string]bool {
+	names := map[string]bool{}
+	for _, decl := range src.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			if typeSpec, ok := spec.(*ast.TypeSpec); ok {
+				names[typeSpec.Name.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+// referencesLocalTypes reports whether any mocked method's parameters or
+// results reference one of localTypes.
+func referencesLocalTypes(interfaces []namedInterface, localTypes map[string]bool) bool {
+	if len(localTypes) == 0 {
+		return false
+	}
+
+	for _, iface := range interfaces {
+		for _, method := range iface.typ.Methods.List {
+			funcType, ok := method.Type.(*ast.FuncType)
+			if !ok {
+				continue
+			}
+			if fieldListReferencesLocal(funcType.Params, localTypes) ||
+				fieldListReferencesLocal(funcType.Results, localTypes) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func fieldListReferencesLocal(list *ast.FieldList, localTypes map[string]bool) bool {
+	if list == nil {
+		return false
+	}
+
+	for _, field := range list.List {
+		if exprReferencesLocal(field.Type, localTypes) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// exprReferencesLocal mirrors typeRenderer.qualify's traversal, reporting
+// whether expr contains a bare identifier naming one of localTypes.
+func exprReferencesLocal(expr ast.Expr, localTypes map[string]bool) bool {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return localTypes[e.Name]
+	case *ast.StarExpr:
+		return exprReferencesLocal(e.X, localTypes)
+	case *ast.ArrayType:
+		return exprReferencesLocal(e.Elt, localTypes)
+	case *ast.Ellipsis:
+		return exprReferencesLocal(e.Elt, localTypes)
+	case *ast.MapType:
+		return exprReferencesLocal(e.Key, localTypes) || exprReferencesLocal(e.Value, localTypes)
+	case *ast.ChanType:
+		return exprReferencesLocal(e.Value, localTypes)
+	default:
+		return false
+	}
+}
+
+// renderImports writes an import block containing "runtime" and
+// "github.com/jacobsa/oglemock" (always needed by the generated mocks),
+// -source-package if needsSourceImport says a mocked method references a
+// type declared in -source itself, and whichever of the source file's own
+// imports are actually referenced by the methods being mocked, skipping any
+// that duplicate the fixed ones. Pulling in every import from the source
+// file regardless of use produces a generated file that fails to compile,
+// either with "imported and not used" or (if the source itself imports
+// "runtime" or oglemock) a duplicate import.
+func renderImports(buf *bytes.Buffer, src *ast.File, interfaces []namedInterface, needsSourceImport bool) error {
+	const (
+		runtimePath  = `"runtime"`
+		oglemockPath = `"github.com/jacobsa/oglemock"`
+	)
+
+	used := map[string]bool{}
+	for _, iface := range interfaces {
+		for _, method := range iface.typ.Methods.List {
+			funcType, ok := method.Type.(*ast.FuncType)
+			if !ok {
+				continue
+			}
+
+			for ident := range selectorPackageIdents(funcType.Params) {
+				used[ident] = true
+			}
+			for ident := range selectorPackageIdents(funcType.Results) {
+				used[ident] = true
+			}
+		}
+	}
+
+	fmt.Fprintf(buf, "import (\n")
+	fmt.Fprintf(buf, "\t%s\n", runtimePath)
+	fmt.Fprintf(buf, "\t%s\n", oglemockPath)
+
+	if needsSourceImport {
+		fmt.Fprintf(buf, "\t%q\n", *fSourcePackage)
+	}
+
+	for _, imp := range src.Imports {
+		if imp.Path.Value == runtimePath || imp.Path.Value == oglemockPath {
+			continue
+		}
+
+		if !used[importLocalName(imp)] {
+			continue
+		}
+
+		fmt.Fprintf(buf, "\t%s\n", imp.Path.Value)
+	}
+
+	fmt.Fprintf(buf, ")\n\n")
+	return nil
+}
+
+// importLocalName returns the identifier code within the source file would
+// use to refer to imp: its explicit name if aliased, otherwise the last
+// path component, which is the overwhelmingly common case for packages
+// whose name matches their directory.
+func importLocalName(imp *ast.ImportSpec) string {
+	if imp.Name != nil {
+		return imp.Name.Name
+	}
+
+	return packageLocalName(strings.Trim(imp.Path.Value, `"`))
+}
+
+// packageLocalName returns the identifier code would use to refer to an
+// import of path absent an explicit alias: the last path component, which
+// is the overwhelmingly common case for packages whose name matches their
+// directory.
+func packageLocalName(path string) string {
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		return path[i+1:]
+	}
+
+	return path
+}
+
+// typeRenderer renders a method parameter/result field type into the string
+// written to the generated mock, qualifying any reference to a type
+// declared in the source file itself with sourceQualifier when the mock is
+// generated into a different package (samePackage is false) - such a
+// reference would otherwise resolve to nothing in the generated file.
+type typeRenderer struct {
+	fset            *token.FileSet
+	localTypes      map[string]bool
+	samePackage     bool
+	sourceQualifier string
+}
+
+func (tr *typeRenderer) render(expr ast.Expr) string {
+	return exprString(tr.fset, tr.qualify(expr))
+}
+
+func (tr *typeRenderer) qualify(expr ast.Expr) ast.Expr {
+	if tr.samePackage {
+		return expr
+	}
+
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if tr.localTypes[e.Name] {
+			return &ast.SelectorExpr{X: ast.NewIdent(tr.sourceQualifier), Sel: ast.NewIdent(e.Name)}
+		}
+		return e
+	case *ast.StarExpr:
+		return &ast.StarExpr{X: tr.qualify(e.X)}
+	case *ast.ArrayType:
+		return &ast.ArrayType{Len: e.Len, Elt: tr.qualify(e.Elt)}
+	case *ast.Ellipsis:
+		return &ast.Ellipsis{Elt: tr.qualify(e.Elt)}
+	case *ast.MapType:
+		return &ast.MapType{Key: tr.qualify(e.Key), Value: tr.qualify(e.Value)}
+	case *ast.ChanType:
+		return &ast.ChanType{Dir: e.Dir, Value: tr.qualify(e.Value)}
+	default:
+		return expr
+	}
+}
+
+// selectorPackageIdents returns the set of package identifiers (the "foo"
+// in "foo.Bar") referenced anywhere within list's field types.
+func selectorPackageIdents(list *ast.FieldList) map[string]bool {
+	idents := map[string]bool{}
+	if list == nil {
+		return idents
+	}
+
+	for _, field := range list.List {
+		ast.Inspect(field.Type, func(n ast.Node) bool {
+			sel, ok := n.(*ast.SelectorExpr)
+			if !ok {
+				return true
+			}
+
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				idents[ident.Name] = true
+			}
+
+			return true
+		})
+	}
+
+	return idents
+}
+
+func renderMock(buf *bytes.Buffer, tr *typeRenderer, iface namedInterface) error {
+	mockName := "Mock" + iface.name
+
+	fmt.Fprintf(buf, "type %s struct {\n", mockName)
+	fmt.Fprintf(buf, "\tcontroller  oglemock.Controller\n")
+	fmt.Fprintf(buf, "\tdescription string\n")
+	fmt.Fprintf(buf, "}\n\n")
+
+	fmt.Fprintf(buf, "func New%s(c oglemock.Controller, desc string) %s {\n", mockName, mockName)
+	fmt.Fprintf(buf, "\treturn %s{controller: c, description: desc}\n", mockName)
+	fmt.Fprintf(buf, "}\n\n")
+
+	fmt.Fprintf(buf, "func (m %s) Oglemock_Description() string {\n", mockName)
+	fmt.Fprintf(buf, "\treturn m.description\n")
+	fmt.Fprintf(buf, "}\n\n")
+
+	for _, method := range iface.typ.Methods.List {
+		funcType, ok := method.Type.(*ast.FuncType)
+		if !ok {
+			// This is an embedded interface rather than a method. Rather than
+			// silently generating a mock that's missing its methods (and
+			// failing to satisfy the real interface at the call site with a
+			// confusing compile error), refuse to proceed.
+			return fmt.Errorf(
+				"interface %s embeds %s; oglemockgen does not support embedded "+
+					"interfaces, list its methods explicitly or mock it separately",
+				iface.name, exprString(tr.fset, method.Type))
+		}
+
+		if len(method.Names) == 0 {
+			continue
+		}
+
+		if err := renderMethod(buf, tr, mockName, method.Names[0].Name, funcType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func renderMethod(buf *bytes.Buffer, tr *typeRenderer, mockName string, methodName string, funcType *ast.FuncType) error {
+	params := fieldListTypes(tr, funcType.Params)
+	results := fieldListTypes(tr, funcType.Results)
+
+	paramDecls := make([]string, len(params))
+	argNames := make([]string, len(params))
+	for i, typ := range params {
+		argNames[i] = fmt.Sprintf("p%d", i)
+		paramDecls[i] = fmt.Sprintf("%s %s", argNames[i], typ)
+	}
+
+	resultDecl := ""
+	if len(results) == 1 {
+		resultDecl = " " + results[0]
+	} else if len(results) > 1 {
+		resultDecl = " (" + strings.Join(results, ", ") + ")"
+	}
+
+	fmt.Fprintf(buf, "func (m %s) %s(%s)%s {\n", mockName, methodName, strings.Join(paramDecls, ", "), resultDecl)
+	fmt.Fprintf(buf, "\t_, file, line, _ := runtime.Caller(0)\n")
+	fmt.Fprintf(buf, "\tret := m.controller.HandleMethodCall(\n")
+	fmt.Fprintf(buf, "\t\tm,\n")
+	fmt.Fprintf(buf, "\t\t%q,\n", methodName)
+	fmt.Fprintf(buf, "\t\tfile,\n")
+	fmt.Fprintf(buf, "\t\tline,\n")
+	fmt.Fprintf(buf, "\t\t[]interface{}{%s})\n\n", strings.Join(argNames, ", "))
+
+	if len(results) == 0 {
+		fmt.Fprintf(buf, "}\n\n")
+		return nil
+	}
+
+	retNames := make([]string, len(results))
+	for i, typ := range results {
+		retNames[i] = fmt.Sprintf("ret%d", i)
+		fmt.Fprintf(buf, "\tvar %s %s\n", retNames[i], typ)
+		fmt.Fprintf(buf, "\tif ret[%d] != nil {\n", i)
+		fmt.Fprintf(buf, "\t\t%s = ret[%d].(%s)\n", retNames[i], i, typ)
+		fmt.Fprintf(buf, "\t}\n\n")
+	}
+
+	fmt.Fprintf(buf, "\treturn %s\n", strings.Join(retNames, ", "))
+	fmt.Fprintf(buf, "}\n\n")
+
+	return nil
+}
+
+// fieldListTypes flattens an *ast.FieldList (which may group several names
+// under one type, e.g. "a, b int") into one rendered type string per
+// parameter or result value.
+func fieldListTypes(tr *typeRenderer, list *ast.FieldList) []string {
+	if list == nil {
+		return nil
+	}
+
+	var out []string
+	for _, field := range list.List {
+		typeStr := tr.render(field.Type)
+
+		n := len(field.Names)
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, typeStr)
+		}
+	}
+
+	return out
+}
+
+func exprString(fset *token.FileSet, expr ast.Expr) string {
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, expr); err != nil {
+		return fmt.Sprintf("%v", expr)
+	}
+
+	return buf.String()
+}
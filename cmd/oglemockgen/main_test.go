@@ -0,0 +1,291 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+const sourceWithImports = `
+package foo
+
+import (
+	"io"
+	"time"
+
+	"github.com/jacobsa/oglemock"
+)
+
+type Foo interface {
+	Read(r io.Reader) (time.Duration, error)
+	Unused(oglemock.Controller)
+}
+`
+
+func TestFindInterfacesLocatesRequestedTypes(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", sourceWithImports, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	found, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+	if len(found) != 1 || found[0].name != "Foo" {
+		t.Fatalf("got %+v, want a single Foo entry", found)
+	}
+}
+
+func TestFindInterfacesReportsMissingNames(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", sourceWithImports, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	_, err = findInterfaces(file, []string{"Foo", "Bar"})
+	if err == nil || !strings.Contains(err.Error(), "Bar") {
+		t.Fatalf("expected an error naming Bar, got %v", err)
+	}
+}
+
+func TestImportLocalNameUsesAliasWhenPresent(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", `
+package foo
+
+import aliased "io"
+`, 0)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	if got, want := importLocalName(file.Imports[0]), "aliased"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestImportLocalNameFallsBackToLastPathComponent(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", `
+package foo
+
+import "github.com/jacobsa/oglemock"
+`, 0)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	if got, want := importLocalName(file.Imports[0]), "oglemock"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestRenderImportsOnlyEmitsImportsActuallyUsed(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", sourceWithImports, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	interfaces, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderImports(&buf, file, interfaces, false); err != nil {
+		t.Fatalf("renderImports: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{`"runtime"`, `"github.com/jacobsa/oglemock"`, `"io"`, `"time"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %s; got:\n%s", want, out)
+		}
+	}
+
+	// oglemock is referenced directly as a parameter type (not just used as
+	// one of the two always-emitted imports) and should appear exactly once.
+	if n := strings.Count(out, `"github.com/jacobsa/oglemock"`); n != 1 {
+		t.Errorf("expected exactly one oglemock import line, got %d in:\n%s", n, out)
+	}
+}
+
+func TestRenderImportsOmitsUnusedSourceImports(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", `
+package foo
+
+import (
+	"io"
+	"os"
+)
+
+type Foo interface {
+	Read(r io.Reader) error
+}
+`, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	interfaces, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderImports(&buf, file, interfaces, false); err != nil {
+		t.Fatalf("renderImports: %v", err)
+	}
+
+	if out := buf.String(); strings.Contains(out, `"os"`) {
+		t.Errorf("unused import %q should have been omitted; got:\n%s", `"os"`, out)
+	}
+}
+
+func TestRenderMockErrorsOnEmbeddedInterface(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", `
+package foo
+
+type Base interface {
+	Close() error
+}
+
+type Foo interface {
+	Base
+	Read() error
+}
+`, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	interfaces, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+
+	var buf bytes.Buffer
+	tr := &typeRenderer{fset: fset, samePackage: true}
+	err = renderMock(&buf, tr, interfaces[0])
+	if err == nil {
+		t.Fatal("expected renderMock to reject an embedded interface")
+	}
+	if !strings.Contains(err.Error(), "Base") {
+		t.Errorf("expected the error to name the embedded interface; got %v", err)
+	}
+}
+
+const sourceWithLocalType = `
+package foo
+
+type LocalThing struct{}
+
+type Foo interface {
+	Get() *LocalThing
+}
+`
+
+func TestRenderMocksQualifiesLocalTypeWhenGeneratingIntoAnotherPackage(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", sourceWithLocalType, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	interfaces, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+
+	oldSourcePackage := *fSourcePackage
+	*fSourcePackage = "github.com/jacobsa/oglemock/foo"
+	defer func() { *fSourcePackage = oldSourcePackage }()
+
+	var buf bytes.Buffer
+	if err := renderMocks(&buf, fset, file, "mock_foo", interfaces); err != nil {
+		t.Fatalf("renderMocks: %v", err)
+	}
+
+	out := buf.String()
+	if !strings.Contains(out, `"github.com/jacobsa/oglemock/foo"`) {
+		t.Errorf("expected output to import the source package; got:\n%s", out)
+	}
+	if !strings.Contains(out, "foo.LocalThing") {
+		t.Errorf("expected output to qualify LocalThing with foo.; got:\n%s", out)
+	}
+}
+
+func TestRenderMocksDoesNotQualifyLocalTypeWhenGeneratingIntoTheSourcePackage(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", sourceWithLocalType, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	interfaces, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderMocks(&buf, fset, file, "foo", interfaces); err != nil {
+		t.Fatalf("renderMocks: %v", err)
+	}
+
+	out := buf.String()
+	if strings.Contains(out, "foo.LocalThing") {
+		t.Errorf("expected LocalThing to stay unqualified within its own package; got:\n%s", out)
+	}
+	if !strings.Contains(out, "*LocalThing") {
+		t.Errorf("expected output to reference LocalThing; got:\n%s", out)
+	}
+}
+
+func TestRenderMocksErrorsWhenSourcePackageIsNeededButMissing(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "source.go", sourceWithLocalType, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing test source: %v", err)
+	}
+
+	interfaces, err := findInterfaces(file, []string{"Foo"})
+	if err != nil {
+		t.Fatalf("findInterfaces: %v", err)
+	}
+
+	oldSourcePackage := *fSourcePackage
+	*fSourcePackage = ""
+	defer func() { *fSourcePackage = oldSourcePackage }()
+
+	var buf bytes.Buffer
+	err = renderMocks(&buf, fset, file, "mock_foo", interfaces)
+	if err == nil {
+		t.Fatal("expected renderMocks to reject a missing -source-package")
+	}
+	if !strings.Contains(err.Error(), "-source-package") {
+		t.Errorf("expected the error to name -source-package; got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"path"
+	"runtime"
+
+	"github.com/jacobsa/oglemock"
+)
+
+// PartialExpectation is returned by ExpectCall. It's "partial" because the
+// expectation isn't registered with the mock controller until WithArgs is
+// called; until then the receiver is just remembering the object, method
+// name, and call site that ExpectCall discovered on its behalf.
+//
+// Once WithArgs has been called, the chained WillOnce, WillRepeatedly,
+// Times, and AnyTimes methods set up the expectation's cardinality and
+// actions, mirroring the EXPECT().Method().Return().Times() style familiar
+// from gomock.
+type PartialExpectation struct {
+	controller oglemock.Controller
+	obj        interface{}
+	methodName string
+	fileName   string
+	lineNumber int
+
+	spec oglemock.MockCallSpecification
+}
+
+// ExpectCall begins an expectation that methodName will be called on
+// obj, which must be a mock created with a MockController belonging to the
+// currently-running test. The returned PartialExpectation must have
+// WithArgs called on it to actually take effect.
+//
+// The call site reported in failure messages is discovered automatically
+// via runtime.Caller, so unlike calling the controller's ExpectCall method
+// directly, there's no need to hard-code a file name and line number at
+// each call site.
+func ExpectCall(obj interface{}, methodName string) *PartialExpectation {
+	_, file, line, _ := runtime.Caller(1)
+
+	return &PartialExpectation{
+		controller: getCurrentTest().MockController,
+		obj:        obj,
+		methodName: methodName,
+		fileName:   path.Base(file),
+		lineNumber: line,
+	}
+}
+
+// WithArgs supplies the matchers that incoming calls must satisfy and
+// actually registers the expectation with the mock controller. It must be
+// called exactly once, before any of the cardinality/action methods below.
+func (e *PartialExpectation) WithArgs(matchers ...interface{}) *PartialExpectation {
+	e.spec = e.controller.ExpectCall(
+		e.obj,
+		e.methodName,
+		e.fileName,
+		e.lineNumber)(matchers...)
+
+	return e
+}
+
+// WillOnce causes the next matching call to invoke action, consuming one
+// unit of the expectation's cardinality.
+func (e *PartialExpectation) WillOnce(action oglemock.Action) *PartialExpectation {
+	e.spec.WillOnce(action)
+	return e
+}
+
+// WillRepeatedly causes every subsequent matching call to invoke action.
+func (e *PartialExpectation) WillRepeatedly(action oglemock.Action) *PartialExpectation {
+	e.spec.WillRepeatedly(action)
+	return e
+}
+
+// Times requires that the expectation be satisfied exactly n times.
+func (e *PartialExpectation) Times(n uint) *PartialExpectation {
+	e.spec.Times(n)
+	return e
+}
+
+// AnyTimes allows the expectation to be satisfied any number of times,
+// including zero.
+func (e *PartialExpectation) AnyTimes() *PartialExpectation {
+	e.spec.AnyTimes()
+	return e
+}
+
+// InOrder requires that every call in calls happen in the order given,
+// relative to the others. It's a thin wrapper around oglemock.InOrder that
+// lets callers pass the PartialExpectations returned by ExpectCall/WithArgs
+// directly instead of reaching into their underlying specs.
+func InOrder(calls ...*PartialExpectation) {
+	specs := make([]oglemock.MockCallSpecification, len(calls))
+	for i, c := range calls {
+		specs[i] = c.spec
+	}
+
+	oglemock.InOrder(specs...)
+}
@@ -0,0 +1,175 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"bytes"
+	"flag"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// ogletestParallel controls how many test suites are run concurrently by
+// RunTests. A value of 1 (the default) preserves the historical strictly
+// sequential behavior.
+var ogletestParallel = flag.Int(
+	"ogletest.parallel",
+	1,
+	"Number of test suites to run concurrently.")
+
+// runningTests maps goroutine ID to the *TestInfo owned by that goroutine,
+// so that many suites can each look up their own current test at once,
+// which the package-level currentlyRunningTest cannot do. Each worker goroutine spawned by runSuitesInParallel owns exactly
+// one entry for as long as it's running a test.
+var runningTests sync.Map // goroutine ID (uint64) -> *TestInfo
+
+// goroutineID returns an identifier for the calling goroutine, parsed out of
+// its stack trace. It exists solely to key runningTests; callers shouldn't
+// read anything else into the value.
+func goroutineID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	b := buf[:n]
+
+	// The stack trace starts with "goroutine 123 [running]:".
+	const prefix = "goroutine "
+	if !bytes.HasPrefix(b, []byte(prefix)) {
+		return 0
+	}
+	b = b[len(prefix):]
+
+	i := bytes.IndexByte(b, ' ')
+	if i < 0 {
+		return 0
+	}
+
+	id, err := strconv.ParseUint(string(b[:i]), 10, 64)
+	if err != nil {
+		return 0
+	}
+
+	return id
+}
+
+// setCurrentTest associates i with the calling goroutine.
+func setCurrentTest(i *TestInfo) {
+	runningTests.Store(goroutineID(), i)
+}
+
+// clearCurrentTest removes the calling goroutine's association, if any.
+func clearCurrentTest() {
+	runningTests.Delete(goroutineID())
+}
+
+// getCurrentTest returns the *TestInfo associated with the calling
+// goroutine, or nil if there is none. This replaces direct reads of the
+// package-level currentlyRunningTest, which cannot be shared safely across
+// goroutines running unrelated tests.
+func getCurrentTest() *TestInfo {
+	v, ok := runningTests.Load(goroutineID())
+	if !ok {
+		return nil
+	}
+
+	return v.(*TestInfo)
+}
+
+// suiteJob is a unit of work handed to the parallel worker pool: a single
+// registered suite along with the methods within it that should run.
+type suiteJob struct {
+	suite       interface{}
+	suiteName   string
+	testMethods []reflect.Method
+}
+
+// recordingReporter implements Reporter by recording each call it receives
+// as a closure, rather than acting on it immediately. Replaying the
+// recording against a real Reporter later reproduces exactly what would
+// have happened had the real Reporter been called directly. This is how
+// runSuitesInParallel keeps concurrently-running suites from interleaving
+// their output: each worker reports into its own recordingReporter, and the
+// recordings are replayed into the real Reporter one at a time, in job
+// order, once every job has finished.
+type recordingReporter struct {
+	calls []func(Reporter)
+}
+
+func (r *recordingReporter) SuiteStarted(suiteName string) {
+	r.calls = append(r.calls, func(real Reporter) { real.SuiteStarted(suiteName) })
+}
+
+func (r *recordingReporter) TestStarted(suiteName string, testName string) {
+	r.calls = append(r.calls, func(real Reporter) { real.TestStarted(suiteName, testName) })
+}
+
+func (r *recordingReporter) TestFinished(
+	suiteName string,
+	testName string,
+	failures []*failureRecord,
+	duration time.Duration) {
+	r.calls = append(r.calls, func(real Reporter) {
+		real.TestFinished(suiteName, testName, failures, duration)
+	})
+}
+
+func (r *recordingReporter) SuiteFinished(suiteName string) {
+	r.calls = append(r.calls, func(real Reporter) { real.SuiteFinished(suiteName) })
+}
+
+func (r *recordingReporter) replayInto(real Reporter) {
+	for _, call := range r.calls {
+		call(real)
+	}
+}
+
+// runSuitesInParallel runs each job in jobs on a pool of n workers, each
+// reporting into its own recordingReporter so that concurrently-running
+// suites don't interleave their output. Recordings are replayed into
+// reporter, in job order, only after every job has finished.
+func runSuitesInParallel(t *testing.T, reporter Reporter, jobs []suiteJob, n int) {
+	recordings := make([]*recordingReporter, len(jobs))
+
+	jobCh := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobCh {
+				job := jobs[idx]
+
+				rec := &recordingReporter{}
+				runSuite(t, rec, job.suite, job.suiteName, job.testMethods)
+
+				recordings[idx] = rec
+			}
+		}()
+	}
+
+	for idx := range jobs {
+		jobCh <- idx
+	}
+	close(jobCh)
+	wg.Wait()
+
+	for _, rec := range recordings {
+		rec.replayInto(reporter)
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2011 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGoroutineIDIsDistinctAcrossGoroutines(t *testing.T) {
+	const n = 8
+
+	ids := make([]uint64, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ids[i] = goroutineID()
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[uint64]bool{}
+	for _, id := range ids {
+		if id == 0 {
+			t.Fatalf("goroutineID returned 0, want a real id")
+		}
+		if seen[id] {
+			t.Fatalf("goroutine id %d was reused across concurrent goroutines", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestGoroutineIDIsStableWithinAGoroutine(t *testing.T) {
+	if goroutineID() != goroutineID() {
+		t.Error("goroutineID should return the same value for the same goroutine")
+	}
+}
+
+func TestSetCurrentTestIsPerGoroutine(t *testing.T) {
+	a := newTestInfo()
+	b := newTestInfo()
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		setCurrentTest(a)
+		defer clearCurrentTest()
+		if got := getCurrentTest(); got != a {
+			t.Errorf("got %p, want %p", got, a)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		setCurrentTest(b)
+		defer clearCurrentTest()
+		if got := getCurrentTest(); got != b {
+			t.Errorf("got %p, want %p", got, b)
+		}
+	}()
+
+	wg.Wait()
+
+	if got := getCurrentTest(); got != nil {
+		t.Errorf("expected no current test on this goroutine, got %p", got)
+	}
+}
+
+func TestRecordingReporterReplaysCallsInOrder(t *testing.T) {
+	rec := &recordingReporter{}
+
+	rec.SuiteStarted("SomeSuite")
+	rec.TestStarted("SomeSuite", "TestA")
+	rec.TestFinished("SomeSuite", "TestA", nil, 0)
+	rec.SuiteFinished("SomeSuite")
+
+	real := &recordingCallOrderReporter{}
+	rec.replayInto(real)
+	got := real.calls
+
+	want := []string{
+		"suite_start:SomeSuite",
+		"test_start:SomeSuite.TestA",
+		"test_end:SomeSuite.TestA",
+		"suite_end:SomeSuite",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+}
+
+// recordingCallOrderReporter is a minimal Reporter used to observe the order
+// recordingReporter.replayInto calls its real Reporter in.
+type recordingCallOrderReporter struct {
+	calls []string
+}
+
+func (r *recordingCallOrderReporter) SuiteStarted(suiteName string) {
+	r.calls = append(r.calls, "suite_start:"+suiteName)
+}
+
+func (r *recordingCallOrderReporter) TestStarted(suiteName string, testName string) {
+	r.calls = append(r.calls, "test_start:"+suiteName+"."+testName)
+}
+
+func (r *recordingCallOrderReporter) TestFinished(
+	suiteName string,
+	testName string,
+	failures []*failureRecord,
+	duration time.Duration) {
+	r.calls = append(r.calls, "test_end:"+suiteName+"."+testName)
+}
+
+func (r *recordingCallOrderReporter) SuiteFinished(suiteName string) {
+	r.calls = append(r.calls, "suite_end:"+suiteName)
+}
+
+// raceSuiteA and raceSuiteB stand in for two real test suites for
+// TestRunSuitesInParallelAttributesFailuresToTheRightSuite below. Each
+// records a failure against whatever TestInfo getCurrentTest() returns on
+// its goroutine, the same lookup runWithProtection uses to record a panic, so
+// that the test can tell whether runTest ever reads another suite's state
+// back.
+type raceSuiteA struct{}
+
+func (raceSuiteA) TestOnly() {
+	info := getCurrentTest()
+	info.failureRecords = append(info.failureRecords, &failureRecord{GeneratedError: "A failed"})
+
+	// Give raceSuiteB's goroutine a chance to interleave before we return and
+	// runTest reads back info.failureRecords.
+	time.Sleep(20 * time.Millisecond)
+}
+
+type raceSuiteB struct{}
+
+func (raceSuiteB) TestOnly() {
+	info := getCurrentTest()
+	info.failureRecords = append(info.failureRecords, &failureRecord{GeneratedError: "B failed"})
+}
+
+func methodNamed(suite interface{}, name string) reflect.Method {
+	m, ok := reflect.TypeOf(suite).MethodByName(name)
+	if !ok {
+		panic("no such method: " + name)
+	}
+	return m
+}
+
+// capturingReporter records the failures TestFinished was called with,
+// keyed by suite name, so a test can check that each suite's failures were
+// attributed to it and not to some other suite running concurrently.
+type capturingReporter struct {
+	mu       sync.Mutex
+	failures map[string][]*failureRecord
+}
+
+func (r *capturingReporter) SuiteStarted(suiteName string)          {}
+func (r *capturingReporter) TestStarted(suiteName, testName string) {}
+func (r *capturingReporter) SuiteFinished(suiteName string)         {}
+
+func (r *capturingReporter) TestFinished(
+	suiteName string,
+	testName string,
+	failures []*failureRecord,
+	duration time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.failures == nil {
+		r.failures = map[string][]*failureRecord{}
+	}
+	r.failures[suiteName] = failures
+}
+
+func TestRunSuitesInParallelAttributesFailuresToTheRightSuite(t *testing.T) {
+	jobs := []suiteJob{
+		{
+			suite:       &raceSuiteA{},
+			suiteName:   "raceSuiteA",
+			testMethods: []reflect.Method{methodNamed(&raceSuiteA{}, "TestOnly")},
+		},
+		{
+			suite:       &raceSuiteB{},
+			suiteName:   "raceSuiteB",
+			testMethods: []reflect.Method{methodNamed(&raceSuiteB{}, "TestOnly")},
+		},
+	}
+
+	reporter := &capturingReporter{}
+
+	// runTest only trusts its local TestInfo (rather than also writing the
+	// legacy package-level currentlyRunningTest) once --ogletest.parallel
+	// says suites might really run concurrently; set it here so this test
+	// exercises that path the same way RunTests would under
+	// -ogletest.parallel=2.
+	oldParallel := *ogletestParallel
+	*ogletestParallel = 2
+	defer func() { *ogletestParallel = oldParallel }()
+
+	// Use a throwaway *testing.T rather than the real one: raceSuiteA/B
+	// deliberately record failures to prove attribution works, and we don't
+	// want that to mark this test itself as failed.
+	runSuitesInParallel(new(testing.T), reporter, jobs, 2)
+
+	wantA := []string{"A failed"}
+	wantB := []string{"B failed"}
+
+	if got := generatedErrors(reporter.failures["raceSuiteA"]); !equalStrings(got, wantA) {
+		t.Errorf("raceSuiteA failures = %v, want %v", got, wantA)
+	}
+	if got := generatedErrors(reporter.failures["raceSuiteB"]); !equalStrings(got, wantB) {
+		t.Errorf("raceSuiteB failures = %v, want %v", got, wantB)
+	}
+}
+
+func generatedErrors(records []*failureRecord) (out []string) {
+	for _, r := range records {
+		out = append(out, r.GeneratedError)
+	}
+	return
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
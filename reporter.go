@@ -0,0 +1,296 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var ogletestFormat = flag.String(
+	"ogletest.format",
+	"text",
+	"Output format: text, json, or junit.")
+
+var ogletestOutput = flag.String(
+	"ogletest.output",
+	"",
+	"Path to write structured output to. Defaults to stdout.")
+
+// Reporter receives notifications as RunTests progresses through suites and
+// tests, and is responsible for turning them into output in whatever format
+// it implements. The default, used when --ogletest.format is "text" (or
+// unset), reproduces the traditional [ RUN ]/[ OK ] banner output; other
+// implementations emit machine-readable formats for consumption by CI
+// dashboards and test result aggregators.
+type Reporter interface {
+	// SuiteStarted is called once, before any test in suiteName runs.
+	SuiteStarted(suiteName string)
+
+	// TestStarted is called immediately before testName runs.
+	TestStarted(suiteName string, testName string)
+
+	// TestFinished is called after testName has finished running, with the
+	// failures (if any) it produced and how long it took.
+	TestFinished(suiteName string, testName string, failures []*failureRecord, duration time.Duration)
+
+	// SuiteFinished is called once, after every test in suiteName has run.
+	SuiteFinished(suiteName string)
+}
+
+// flushingReporter is implemented by Reporters that buffer output and need a
+// chance to write it out once RunTests has finished with every suite (JUnit,
+// notably, since its root <testsuites> element can't be opened until we know
+// we're done).
+type flushingReporter interface {
+	Flush() error
+}
+
+// newReporter returns the Reporter selected by --ogletest.format, writing to
+// the destination selected by --ogletest.output (stdout if unset).
+func newReporter() (r Reporter, closeFunc func(), err error) {
+	w := stdout
+	closeFunc = func() {}
+
+	if path := *ogletestOutput; path != "" {
+		f, openErr := os.Create(path)
+		if openErr != nil {
+			return nil, nil, fmt.Errorf("opening --ogletest.output: %v", openErr)
+		}
+
+		w = f
+		closeFunc = func() { f.Close() }
+	}
+
+	switch *ogletestFormat {
+	case "", "text":
+		return &textReporter{w: w}, closeFunc, nil
+
+	case "json":
+		return &jsonReporter{enc: json.NewEncoder(w)}, closeFunc, nil
+
+	case "junit":
+		return &junitReporter{w: w}, closeFunc, nil
+	}
+
+	return nil, nil, fmt.Errorf("unknown --ogletest.format: %q", *ogletestFormat)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Text reporter
+////////////////////////////////////////////////////////////////////////
+
+// textReporter reproduces the historical banner-based output of
+// runTestsInternal.
+type textReporter struct {
+	w io.Writer
+}
+
+func (r *textReporter) SuiteStarted(suiteName string) {
+	fmt.Fprintf(r.w, "[----------] Running tests from %s\n", suiteName)
+}
+
+func (r *textReporter) TestStarted(suiteName string, testName string) {
+	fmt.Fprintf(r.w, "[ RUN      ] %s.%s\n", suiteName, testName)
+}
+
+func (r *textReporter) TestFinished(
+	suiteName string,
+	testName string,
+	failures []*failureRecord,
+	duration time.Duration) {
+	for _, record := range failures {
+		userErrorSection := ""
+		if record.UserError != "" {
+			userErrorSection = record.UserError + "\n"
+		}
+
+		fmt.Fprintf(
+			r.w,
+			"%s:%d:\n%s\n%s\n",
+			record.FileName,
+			record.LineNumber,
+			record.GeneratedError,
+			userErrorSection)
+	}
+
+	bannerMessage := "[       OK ]"
+	if len(failures) != 0 {
+		bannerMessage = "[  FAILED  ]"
+	}
+
+	var timeMessage string
+	if duration >= 25*time.Millisecond {
+		timeMessage = fmt.Sprintf(" (%s)", duration.String())
+	}
+
+	fmt.Fprintf(r.w, "%s %s.%s%s\n", bannerMessage, suiteName, testName, timeMessage)
+}
+
+func (r *textReporter) SuiteFinished(suiteName string) {
+	fmt.Fprintf(r.w, "[----------] Finished with tests from %s\n", suiteName)
+}
+
+////////////////////////////////////////////////////////////////////////
+// JSON reporter
+////////////////////////////////////////////////////////////////////////
+
+// jsonEvent is a single line emitted by the JSON reporter, similar in spirit
+// to the stream produced by `go test -json`.
+type jsonEvent struct {
+	Action      string   `json:"action"`
+	Suite       string   `json:"suite"`
+	Test        string   `json:"test,omitempty"`
+	Failed      bool     `json:"failed,omitempty"`
+	DurationSec float64  `json:"durationSeconds,omitempty"`
+	Failures    []string `json:"failures,omitempty"`
+}
+
+type jsonReporter struct {
+	enc *json.Encoder
+}
+
+func (r *jsonReporter) SuiteStarted(suiteName string) {
+	r.enc.Encode(jsonEvent{Action: "suite_start", Suite: suiteName})
+}
+
+func (r *jsonReporter) TestStarted(suiteName string, testName string) {
+	r.enc.Encode(jsonEvent{Action: "test_start", Suite: suiteName, Test: testName})
+}
+
+func (r *jsonReporter) TestFinished(
+	suiteName string,
+	testName string,
+	failures []*failureRecord,
+	duration time.Duration) {
+	event := jsonEvent{
+		Action:      "test_end",
+		Suite:       suiteName,
+		Test:        testName,
+		Failed:      len(failures) != 0,
+		DurationSec: duration.Seconds(),
+	}
+
+	for _, f := range failures {
+		event.Failures = append(event.Failures, fmt.Sprintf(
+			"%s:%d: %s", f.FileName, f.LineNumber, f.GeneratedError))
+	}
+
+	r.enc.Encode(event)
+}
+
+func (r *jsonReporter) SuiteFinished(suiteName string) {
+	r.enc.Encode(jsonEvent{Action: "suite_end", Suite: suiteName})
+}
+
+////////////////////////////////////////////////////////////////////////
+// JUnit reporter
+////////////////////////////////////////////////////////////////////////
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Stack   string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Seconds   float64       `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitReporter buffers every suite's test cases in memory and writes a
+// single <testsuites> document to w when Flush is called, since JUnit's
+// format doesn't support streaming a partial document.
+type junitReporter struct {
+	w      io.Writer
+	suites []junitTestSuite
+	cur    junitTestSuite
+}
+
+func (r *junitReporter) SuiteStarted(suiteName string) {
+	r.cur = junitTestSuite{Name: suiteName}
+}
+
+func (r *junitReporter) TestStarted(suiteName string, testName string) {}
+
+func (r *junitReporter) TestFinished(
+	suiteName string,
+	testName string,
+	failures []*failureRecord,
+	duration time.Duration) {
+	tc := junitTestCase{
+		Name:      testName,
+		ClassName: suiteName,
+		Seconds:   duration.Seconds(),
+	}
+
+	if len(failures) != 0 {
+		var stack string
+		for _, f := range failures {
+			stack += fmt.Sprintf("%s:%d:\n%s\n\n", f.FileName, f.LineNumber, f.GeneratedError)
+		}
+
+		tc.Failure = &junitFailure{
+			Message: fmt.Sprintf("%d failure(s)", len(failures)),
+			Type:    "panic",
+			Stack:   stack,
+		}
+
+		r.cur.Failures++
+	}
+
+	r.cur.Tests++
+	r.cur.TestCases = append(r.cur.TestCases, tc)
+}
+
+func (r *junitReporter) SuiteFinished(suiteName string) {
+	r.suites = append(r.suites, r.cur)
+}
+
+func (r *junitReporter) Flush() error {
+	doc := junitTestSuites{Suites: r.suites}
+
+	bytes, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(r.w, xml.Header); err != nil {
+		return err
+	}
+
+	_, err = r.w.Write(bytes)
+	return err
+}
@@ -0,0 +1,147 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTextReporterBanners(t *testing.T) {
+	var buf bytes.Buffer
+	r := &textReporter{w: &buf}
+
+	r.SuiteStarted("SomeSuite")
+	r.TestStarted("SomeSuite", "SomeTest")
+	r.TestFinished("SomeSuite", "SomeTest", nil, time.Millisecond)
+	r.SuiteFinished("SomeSuite")
+
+	out := buf.String()
+	for _, want := range []string{
+		"[----------] Running tests from SomeSuite",
+		"[ RUN      ] SomeSuite.SomeTest",
+		"[       OK ] SomeSuite.SomeTest",
+		"[----------] Finished with tests from SomeSuite",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q; got:\n%s", want, out)
+		}
+	}
+}
+
+func TestTextReporterFailureBanner(t *testing.T) {
+	var buf bytes.Buffer
+	r := &textReporter{w: &buf}
+
+	failures := []*failureRecord{
+		{FileName: "foo_test.go", LineNumber: 42, GeneratedError: "expected X, got Y"},
+	}
+	r.TestFinished("SomeSuite", "SomeTest", failures, 0)
+
+	out := buf.String()
+	if !strings.Contains(out, "[  FAILED  ] SomeSuite.SomeTest") {
+		t.Errorf("expected a FAILED banner; got:\n%s", out)
+	}
+	if !strings.Contains(out, "foo_test.go:42") {
+		t.Errorf("expected the failure's file:line; got:\n%s", out)
+	}
+}
+
+func TestJSONReporterEmitsOneEventPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	r := &jsonReporter{enc: json.NewEncoder(&buf)}
+
+	r.SuiteStarted("SomeSuite")
+	r.TestStarted("SomeSuite", "SomeTest")
+	r.TestFinished("SomeSuite", "SomeTest", nil, time.Millisecond)
+	r.SuiteFinished("SomeSuite")
+
+	dec := json.NewDecoder(&buf)
+
+	var wantActions = []string{"suite_start", "test_start", "test_end", "suite_end"}
+	for _, wantAction := range wantActions {
+		var event jsonEvent
+		if err := dec.Decode(&event); err != nil {
+			t.Fatalf("decoding event: %v", err)
+		}
+		if event.Action != wantAction {
+			t.Errorf("got action %q, want %q", event.Action, wantAction)
+		}
+	}
+}
+
+func TestJSONReporterReportsFailures(t *testing.T) {
+	var buf bytes.Buffer
+	r := &jsonReporter{enc: json.NewEncoder(&buf)}
+
+	failures := []*failureRecord{
+		{FileName: "foo_test.go", LineNumber: 42, GeneratedError: "boom"},
+	}
+	r.TestFinished("SomeSuite", "SomeTest", failures, 0)
+
+	var event jsonEvent
+	if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+		t.Fatalf("unmarshaling event: %v", err)
+	}
+
+	if !event.Failed {
+		t.Error("expected Failed to be true")
+	}
+	if len(event.Failures) != 1 {
+		t.Errorf("expected one failure entry, got %d", len(event.Failures))
+	}
+}
+
+func TestJUnitReporterFlush(t *testing.T) {
+	var buf bytes.Buffer
+	r := &junitReporter{w: &buf}
+
+	r.SuiteStarted("SomeSuite")
+	r.TestStarted("SomeSuite", "Passes")
+	r.TestFinished("SomeSuite", "Passes", nil, time.Millisecond)
+	r.TestStarted("SomeSuite", "Fails")
+	r.TestFinished(
+		"SomeSuite",
+		"Fails",
+		[]*failureRecord{{FileName: "foo_test.go", LineNumber: 1, GeneratedError: "boom"}},
+		time.Millisecond)
+	r.SuiteFinished("SomeSuite")
+
+	if err := r.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	var doc junitTestSuites
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unmarshaling junit output: %v\n%s", err, buf.String())
+	}
+
+	if len(doc.Suites) != 1 {
+		t.Fatalf("got %d suites, want 1", len(doc.Suites))
+	}
+
+	suite := doc.Suites[0]
+	if suite.Tests != 2 {
+		t.Errorf("got %d tests, want 2", suite.Tests)
+	}
+	if suite.Failures != 1 {
+		t.Errorf("got %d failures, want 1", suite.Failures)
+	}
+}
@@ -19,15 +19,23 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"io"
+	"os"
 	"path"
 	"reflect"
 	"regexp"
 	"runtime"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 )
 
+// stdout is where the default text Reporter writes banners and failure
+// output. It's a variable, rather than a direct reference to os.Stdout, so
+// that tests of the reporters themselves can substitute a buffer.
+var stdout io.Writer = os.Stdout
+
 var testFilter = flag.String("ogletest.run", "", "Regexp for matching tests to run.")
 
 // runTestsOnce protects RunTests from executing multiple times.
@@ -38,26 +46,65 @@ func isAssertThatError(x interface{}) bool {
 	return ok
 }
 
-// runTest runs a single test, returning a slice of failure records for that test.
-func runTest(suite interface{}, method reflect.Method) (failures []*failureRecord) {
+// runTest runs a single test, returning a slice of failure records for that
+// test along with how long it took to run. Duration is measured using the
+// test's Clock, so a suite that has installed a FakeClock in SetUp gets a
+// deterministic, fake-time-driven duration rather than a wall-clock one.
+func runTest(
+	suite interface{},
+	method reflect.Method,
+	reporter Reporter,
+	suiteName string) (failures []*failureRecord, runDuration time.Duration) {
 	suiteValue := reflect.ValueOf(suite)
 	suiteType := suiteValue.Type()
 
-	// Set up a clean slate for this test. Make sure to reset it after everything
-	// below is finished, so we don't accidentally use it elsewhere.
-	currentlyRunningTest = newTestInfo()
-	defer func() {
-		currentlyRunningTest = nil
-	}()
+	// Set up a clean slate for this test, and register it in the
+	// goroutine-local map (see parallel.go) so that runWithProtection,
+	// TestInfo.Run, and TestInfo.Cleanup can look it up safely no matter how
+	// many other suites are running on other goroutines at once. Everything
+	// below reads back through the local info, never through the
+	// package-level currentlyRunningTest, so that one worker can never read
+	// back another worker's start time, mock controller, or failure records.
+	info := newTestInfo()
+	setCurrentTest(info)
+	defer clearCurrentTest()
+
+	// AssertThat/ExpectThat and other helpers elsewhere in the package read
+	// the package-level currentlyRunningTest directly rather than going
+	// through getCurrentTest, so we still need to maintain it for them. That
+	// assignment is itself only safe when suites run one at a time, so we
+	// only do it with -ogletest.parallel left at its default of 1; with
+	// -ogletest.parallel > 1 we leave the global untouched rather than race
+	// on it, which means only TestInfo-based APIs (Run, Cleanup, TempDir,
+	// Clock) are safe to use from within a test running under it.
+	if *ogletestParallel <= 1 {
+		currentlyRunningTest = info
+		defer func() { currentlyRunningTest = nil }()
+	}
+
+	// Make the reporter and the test/suite names available to
+	// TestInfo.Run, so that subtests can emit their own banners and be
+	// matched individually by --ogletest.run.
+	setRunContext(&runContext{suiteName: suiteName, methodName: method.Name, reporter: reporter})
+	defer clearRunContext()
 
 	// Create a receiver.
 	var suiteInstance reflect.Value = reflect.New(suiteType.Elem())
 	var suiteInstanceAsInterface interface{} = suiteInstance.Interface()
 
+	startClock := info.Clock
+	startTime := startClock.Now()
+
 	// Run the SetUp method, paying attention to whether it panics.
 	setUpPanicked := false
 	if i, ok := suiteInstanceAsInterface.(SetUpInterface); ok {
-		setUpPanicked = runWithProtection(func() { i.SetUp(currentlyRunningTest) })
+		setUpPanicked = runWithProtection(func() { i.SetUp(info) })
+	}
+
+	// If SetUp installed a different Clock, restart the measurement on it so
+	// that the duration never mixes readings from two clocks.
+	if info.Clock != startClock {
+		startTime = info.Clock.Now()
 	}
 
 	// Run the test method itself, but only if the SetUp method didn't panic.
@@ -75,11 +122,18 @@ func runTest(suite interface{}, method reflect.Method) (failures []*failureRecor
 		runWithProtection(func() { i.TearDown() })
 	}
 
+	// Run any cleanup functions registered with TestInfo.Cleanup, in LIFO
+	// order. Each runs under the same panic protection as SetUp/TearDown, so
+	// a panicking cleanup doesn't prevent the rest from running.
+	runCleanups(info)
+
+	runDuration = info.Clock.Since(startTime)
+
 	// Tell the mock controller for the tests to report any errors it's sitting
 	// on.
-	currentlyRunningTest.MockController.Finish()
+	info.MockController.Finish()
 
-	return currentlyRunningTest.failureRecords
+	return info.failureRecords, runDuration
 }
 
 // RunTests runs the test suites registered with ogletest, communicating
@@ -106,81 +160,91 @@ func RunTests(t *testing.T) {
 // runTestsInternal does the real work of RunTests, which simply wraps it in a
 // sync.Once.
 func runTestsInternal(t *testing.T) {
-	// Process each registered suite.
+	// Build up the list of suites with work left to do.
+	var jobs []suiteJob
 	for _, suite := range testSuites {
-		val := reflect.ValueOf(suite)
-		typ := val.Type()
+		typ := reflect.ValueOf(suite).Type()
 		suiteName := typ.Elem().Name()
 
 		// Grab methods for the suite, filtering them to just the ones that we
 		// don't need to skip.
 		testMethods := filterMethods(suiteName, getMethodsInSourceOrder(typ))
-
-		// Is there anything left to do?
 		if len(testMethods) == 0 {
 			continue
 		}
 
-		fmt.Printf("[----------] Running tests from %s\n", suiteName)
+		jobs = append(jobs, suiteJob{
+			suite:       suite,
+			suiteName:   suiteName,
+			testMethods: testMethods,
+		})
+	}
 
-		// Run the SetUpTestSuite method, if any.
-		if i, ok := suite.(SetUpTestSuiteInterface); ok {
-			i.SetUpTestSuite()
+	// Build the reporter selected by --ogletest.format/--ogletest.output. The
+	// text reporter reproduces the traditional banner output; json and junit
+	// emit machine-readable formats instead.
+	reporter, closeFunc, err := newReporter()
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer closeFunc()
+
+	// Run each suite, serially by default or concurrently if the caller has
+	// opted in via --ogletest.parallel. Suites are always isolated from one
+	// another (separate TestInfo, separate mock Controller). On the concurrent
+	// path the package-level currentlyRunningTest is left unset, so tests run
+	// there can only use the TestInfo-based APIs (see runTest).
+	if n := *ogletestParallel; n > 1 {
+		runSuitesInParallel(t, reporter, jobs, n)
+	} else {
+		for _, job := range jobs {
+			runSuite(t, reporter, job.suite, job.suiteName, job.testMethods)
 		}
+	}
 
-		// Run each method.
-		for _, method := range testMethods {
-			// Print a banner for the start of this test.
-			fmt.Printf("[ RUN      ] %s.%s\n", suiteName, method.Name)
-
-			// Run the test.
-			startTime := time.Now()
-			failures := runTest(suite, method)
-			runDuration := time.Since(startTime)
-
-			// Print any failures, and mark the test as having failed if there are any.
-			for _, record := range failures {
-				t.Fail()
-				userErrorSection := ""
-				if record.UserError != "" {
-					userErrorSection = record.UserError + "\n"
-				}
-
-				fmt.Printf(
-					"%s:%d:\n%s\n%s\n",
-					record.FileName,
-					record.LineNumber,
-					record.GeneratedError,
-					userErrorSection)
-			}
-
-			// Print a banner for the end of the test.
-			bannerMessage := "[       OK ]"
-			if len(failures) != 0 {
-				bannerMessage = "[  FAILED  ]"
-			}
-
-			// Print a summary of the time taken, if long enough.
-			var timeMessage string
-			if runDuration >= 25*time.Millisecond {
-				timeMessage = fmt.Sprintf(" (%s)", runDuration.String())
-			}
-
-			fmt.Printf(
-				"%s %s.%s%s\n",
-				bannerMessage,
-				suiteName,
-				method.Name,
-				timeMessage)
+	if f, ok := reporter.(flushingReporter); ok {
+		if err := f.Flush(); err != nil {
+			t.Fatal(err)
 		}
+	}
+}
 
-		// Run the TearDownTestSuite method, if any.
-		if i, ok := suite.(TearDownTestSuiteInterface); ok {
-			i.TearDownTestSuite()
+// runSuite runs every method in testMethods against suite, reporting
+// progress and failures to reporter. It's the unit of work shared by the
+// sequential path above and the parallel worker pool in parallel.go.
+func runSuite(
+	t *testing.T,
+	reporter Reporter,
+	suite interface{},
+	suiteName string,
+	testMethods []reflect.Method) {
+	reporter.SuiteStarted(suiteName)
+
+	// Run the SetUpTestSuite method, if any.
+	if i, ok := suite.(SetUpTestSuiteInterface); ok {
+		i.SetUpTestSuite()
+	}
+
+	// Run each method.
+	for _, method := range testMethods {
+		reporter.TestStarted(suiteName, method.Name)
+
+		// Run the test.
+		failures, runDuration := runTest(suite, method, reporter, suiteName)
+		if len(failures) != 0 {
+			t.Fail()
 		}
 
-		fmt.Printf("[----------] Finished with tests from %s\n", suiteName)
+		reporter.TestFinished(suiteName, method.Name, failures, runDuration)
 	}
+
+	// Run the TearDownTestSuite method, if any.
+	if i, ok := suite.(TearDownTestSuiteInterface); ok {
+		i.TearDownTestSuite()
+	}
+
+	reporter.SuiteFinished(suiteName)
 }
 
 // Return true iff the supplied program counter appears to lie within panic().
@@ -245,7 +309,10 @@ func runWithProtection(f func()) (panicked bool) {
 
 		panicked = true
 
-		// We modify the currently running test below.
+		// We modify the currently running test below. Each goroutine has its
+		// own *TestInfo (see setCurrentTest), so this is the one belonging to
+		// whichever test called us.
+		currentlyRunningTest := getCurrentTest()
 		currentlyRunningTest.mutex.Lock()
 		defer currentlyRunningTest.mutex.Unlock()
 
@@ -343,9 +410,15 @@ func filterMethods(suiteName string, in []reflect.Method) (out []reflect.Method)
 			continue
 		}
 
-		// Has the user told us to skip this method?
+		// Has the user told us to skip this method? A filter may select an
+		// individual subtest as "Suite.Method/subname" (see TestInfo.Run); in
+		// that case we only match the portion of the pattern up to the first
+		// "/" here; once the method is actually running, TestInfo.Run matches
+		// each later "/"-separated element against the subtest name at the
+		// same depth, the same way testing.T's -run does for t.Run.
 		fullName := fmt.Sprintf("%s.%s", suiteName, m.Name)
-		matched, err := regexp.MatchString(*testFilter, fullName)
+		topLevelPattern := strings.SplitN(*testFilter, "/", 2)[0]
+		matched, err := regexp.MatchString(topLevelPattern, fullName)
 		if err != nil {
 			panic("Invalid value for --ogletest.run: " + err.Error())
 		}
@@ -0,0 +1,102 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// runContext holds the pieces of state that TestInfo.Run needs but that
+// aren't part of TestInfo itself: which suite and top-level method are
+// currently running, where to report subtest banners, and the stack of
+// subtest names currently nested (for table-driven tests that call Run
+// inside of Run).
+type runContext struct {
+	suiteName  string
+	methodName string
+	reporter   Reporter
+	frames     []string
+}
+
+// runContexts maps goroutine ID to the runContext for the test currently
+// running on that goroutine, mirroring the per-goroutine association of the
+// current test (runningTests) in parallel.go.
+var runContexts sync.Map // goroutine ID (uint64) -> *runContext
+
+func setRunContext(c *runContext) {
+	runContexts.Store(goroutineID(), c)
+}
+
+func clearRunContext() {
+	runContexts.Delete(goroutineID())
+}
+
+func getRunContext() *runContext {
+	v, ok := runContexts.Load(goroutineID())
+	if !ok {
+		return nil
+	}
+
+	return v.(*runContext)
+}
+
+// Run runs fn as a named subtest of the currently-running test, in the
+// style of testing.T.Run: failures from ExpectThat/AssertThat/panics while
+// fn is running are recorded against the subtest (and reported under its
+// own [ RUN ]/[ OK ] banner), while still causing the enclosing test to be
+// marked failed. Subtests are addressable individually via --ogletest.run,
+// as "Suite.Method/subname"; Run may be called again inside fn to nest
+// further, becoming "Suite.Method/subname/nested".
+func (i *TestInfo) Run(name string, fn func()) {
+	ctx := getRunContext()
+	if ctx == nil {
+		panic("Run called with no test currently running")
+	}
+
+	ctx.frames = append(ctx.frames, name)
+	defer func() { ctx.frames = ctx.frames[:len(ctx.frames)-1] }()
+
+	subPath := strings.Join(ctx.frames, "/")
+
+	// Element k of the "/"-separated filter selects subtests at depth k;
+	// subtests deeper than the filter reaches always run.
+	if parts := strings.Split(*testFilter, "/"); len(ctx.frames) < len(parts) {
+		if matched, err := regexp.MatchString(parts[len(ctx.frames)], name); err == nil && !matched {
+			return
+		}
+	}
+
+	reportedName := fmt.Sprintf("%s/%s", ctx.methodName, subPath)
+	ctx.reporter.TestStarted(ctx.suiteName, reportedName)
+
+	// Temporarily swap in a fresh slate for failures produced by fn, so that
+	// they can be reported against the subtest specifically, then fold them
+	// back into the parent's own failures so it's reported as failed too.
+	parentFailures := i.failureRecords
+	i.failureRecords = nil
+
+	startTime := i.Clock.Now()
+	runWithProtection(fn)
+	duration := i.Clock.Since(startTime)
+
+	subtestFailures := i.failureRecords
+	i.failureRecords = append(parentFailures, subtestFailures...)
+
+	ctx.reporter.TestFinished(ctx.suiteName, reportedName, subtestFailures, duration)
+}
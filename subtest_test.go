@@ -0,0 +1,138 @@
+// Copyright 2012 Aaron Jacobs. All Rights Reserved.
+// Author: aaronjjacobs@gmail.com (Aaron Jacobs)
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ogletest
+
+import (
+	"reflect"
+	"testing"
+)
+
+type subtestFilterFixture struct{}
+
+func (subtestFilterFixture) TestFoo() {}
+func (subtestFilterFixture) TestBar() {}
+
+func methodNames(methods []reflect.Method) (names []string) {
+	for _, m := range methods {
+		names = append(names, m.Name)
+	}
+	return
+}
+
+func withTestFilter(t *testing.T, pattern string, fn func()) {
+	old := *testFilter
+	*testFilter = pattern
+	defer func() { *testFilter = old }()
+	fn()
+}
+
+func TestFilterMethodsMatchesSubtestPatternAgainstItsTopLevelMethod(t *testing.T) {
+	typ := reflect.TypeOf(subtestFilterFixture{})
+	in := getMethodsInSourceOrder(typ)
+
+	withTestFilter(t, `TestFoo/some_case`, func() {
+		out := filterMethods("subtestFilterFixture", in)
+		names := methodNames(out)
+
+		if len(names) != 1 || names[0] != "TestFoo" {
+			t.Errorf(
+				"filtering with a subtest pattern should keep the parent "+
+					"method so TestInfo.Run can apply the rest of the "+
+					"pattern itself; got %v",
+				names)
+		}
+	})
+}
+
+func TestFilterMethodsStillExcludesNonMatchingMethods(t *testing.T) {
+	typ := reflect.TypeOf(subtestFilterFixture{})
+	in := getMethodsInSourceOrder(typ)
+
+	withTestFilter(t, `TestFoo/some_case`, func() {
+		out := filterMethods("subtestFilterFixture", in)
+		for _, m := range out {
+			if m.Name == "TestBar" {
+				t.Errorf("TestBar should not match the TestFoo/... filter")
+			}
+		}
+	})
+}
+
+func TestFilterMethodsWithNoSlashBehavesAsBefore(t *testing.T) {
+	typ := reflect.TypeOf(subtestFilterFixture{})
+	in := getMethodsInSourceOrder(typ)
+
+	withTestFilter(t, `TestBar`, func() {
+		names := methodNames(filterMethods("subtestFilterFixture", in))
+		if len(names) != 1 || names[0] != "TestBar" {
+			t.Errorf("got %v, want only TestBar", names)
+		}
+	})
+}
+
+func TestRunPanicsWithNoCurrentRunContext(t *testing.T) {
+	clearRunContext()
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Run should panic when no test is currently running")
+		}
+	}()
+
+	newTestInfo().Run("subname", func() {})
+}
+
+func TestRunNestsSubtestNamesUnderFrames(t *testing.T) {
+	reporter := &recordingReporter{}
+	ctx := &runContext{
+		suiteName:  "SomeSuite",
+		methodName: "TestFoo",
+		reporter:   reporter,
+	}
+
+	setRunContext(ctx)
+	defer clearRunContext()
+
+	info := newTestInfo()
+	info.Clock = NewClock()
+
+	var sawNestedFrame string
+	info.Run("outer", func() {
+		sawNestedFrame = joinFrames(ctx.frames)
+		info.Run("inner", func() {
+			sawNestedFrame = joinFrames(ctx.frames)
+		})
+	})
+
+	if sawNestedFrame != "outer/inner" {
+		t.Errorf("got frames %q, want %q", sawNestedFrame, "outer/inner")
+	}
+
+	if len(ctx.frames) != 0 {
+		t.Errorf("frames should be empty again after Run returns, got %v", ctx.frames)
+	}
+}
+
+func joinFrames(frames []string) string {
+	out := ""
+	for i, f := range frames {
+		if i > 0 {
+			out += "/"
+		}
+		out += f
+	}
+	return out
+}